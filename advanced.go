@@ -0,0 +1,139 @@
+package firmata
+
+import "fmt"
+
+// Sysex commands and pin modes for the optional Firmata 2.5 feature set:
+// extended analog resolution, servos, OneWire, and stepper motors.
+const (
+	EXTENDED_ANALOG byte = 0x6F // analog write with a 14 bit (or wider) value
+	SERVO_CONFIG    byte = 0x70 // set min/max pulse width and attach a servo
+	STEPPER_DATA    byte = 0x72 // configure and drive a stepper motor
+	ONEWIRE_DATA    byte = 0x73 // OneWire search/read/write
+
+	MODE_ONEWIRE byte = 0x07
+	MODE_STEPPER byte = 0x08
+
+	// Encoder support (pin mode 0x09, ENCODER_DATA sysex 0x61) is part of
+	// the ConfigurableFirmata encoder feature but isn't implemented here:
+	// there's no ENCODER_DATA case in process_sysex to parse the reports,
+	// so a MODE_ENCODER constant with nothing consuming it would just be
+	// a stub. Add both together if a board that needs it comes along.
+)
+
+// OneWire sub-commands, sent as the second byte of a ONEWIRE_DATA sysex
+// message.
+const (
+	ONEWIRE_SEARCH_REQUEST byte = 0x40
+	ONEWIRE_CONFIG_REQUEST byte = 0x41
+	ONEWIRE_SEARCH_REPLY   byte = 0x42
+	ONEWIRE_READ_REPLY     byte = 0x43
+	ONEWIRE_WRITE_REQUEST  byte = 0x44
+	ONEWIRE_READ_REQUEST   byte = 0x45
+)
+
+// Stepper sub-commands, sent as the second byte of a STEPPER_DATA sysex
+// message.
+const (
+	STEPPER_CONFIG byte = 0x00
+	STEPPER_STEP   byte = 0x01
+)
+
+// SetServoConfig attaches pin as a servo and sets its pulse width range,
+// in microseconds. It should be called before WriteServo for that pin.
+func (board *Board) SetServoConfig(pin byte, minPulse, maxPulse int) error {
+	if !board.supportsMode(pin, MODE_SERVO) {
+		return fmt.Errorf("firmata: pin %d does not support %#x (servo)", pin, MODE_SERVO)
+	}
+	minLSB, minMSB := encode14(minPulse)
+	maxLSB, maxMSB := encode14(maxPulse)
+	msg := []byte{SERVO_CONFIG, pin & 0x7F, minLSB, minMSB, maxLSB, maxMSB}
+	board.sendSysex(msg)
+	return nil
+}
+
+// WriteServo moves a servo attached to pin to the given angle, in degrees.
+// Pins 0-15 use the plain ANALOG_MESSAGE, built directly here since
+// WriteAnalog's value is a byte and would truncate any angle over 255;
+// higher pins, or angles that don't fit in 14 bits, use the
+// EXTENDED_ANALOG sysex instead.
+func (board *Board) WriteServo(pin byte, angle int) {
+	lsb, msb := encode14(angle)
+	if pin < 16 && angle <= 16383 {
+		cmd := byte(ANALOG_WRITE | pin)
+		msg := []byte{cmd, lsb, msb}
+		board.sendRaw(&msg)
+		board.analogPins[pin] = angle
+		return
+	}
+	msg := []byte{EXTENDED_ANALOG, pin & 0x7F, lsb, msb}
+	for v := angle >> 14; v > 0; v >>= 7 {
+		msg = append(msg, byte(v&0x7F))
+	}
+	board.sendSysex(msg)
+}
+
+// OneWireConfig configures the OneWire bus attached to pin. power enables
+// the bus's parasite power pin.
+func (board *Board) OneWireConfig(pin byte, power bool) {
+	p := byte(0)
+	if power {
+		p = 1
+	}
+	board.sendSysex([]byte{ONEWIRE_DATA, ONEWIRE_CONFIG_REQUEST, pin & 0x7F, p})
+}
+
+// OneWireSearch asks for the 64 bit addresses of every device on the
+// OneWire bus attached to pin. The reply is delivered as a FirmataMsg on
+// Board.Reader.
+func (board *Board) OneWireSearch(pin byte) {
+	board.sendSysex([]byte{ONEWIRE_DATA, ONEWIRE_SEARCH_REQUEST, pin & 0x7F})
+}
+
+// OneWireRead reads numBytes bytes from device on the OneWire bus attached
+// to pin. The reply is delivered as a FirmataMsg on Board.Reader.
+func (board *Board) OneWireRead(pin byte, device []byte, numBytes int) {
+	lsb, msb := encode14(numBytes)
+	msg := append([]byte{ONEWIRE_DATA, ONEWIRE_READ_REQUEST, pin & 0x7F}, device...)
+	msg = append(msg, lsb, msb)
+	board.sendSysex(msg)
+}
+
+// OneWireWrite writes data to device on the OneWire bus attached to pin.
+func (board *Board) OneWireWrite(pin byte, device []byte, data []byte) {
+	msg := append([]byte{ONEWIRE_DATA, ONEWIRE_WRITE_REQUEST, pin & 0x7F}, device...)
+	msg = append(msg, encode7bit(data)...)
+	board.sendSysex(msg)
+}
+
+// StepperConfig configures a stepper motor. deviceNum identifies the
+// motor for later calls to StepperStep, interfaceType is one of the
+// firmware's stepper interface constants (driver, 2/4-wire, and so on),
+// stepsPerRev is the number of steps for a full revolution, and dirPin /
+// stepPin are the pins driving the motor.
+func (board *Board) StepperConfig(deviceNum, interfaceType byte, stepsPerRev int, dirPin, stepPin byte) {
+	lsb, msb := encode14(stepsPerRev)
+	msg := []byte{
+		STEPPER_DATA, STEPPER_CONFIG, deviceNum & 0x7F, interfaceType & 0x7F,
+		lsb, msb, dirPin & 0x7F, stepPin & 0x7F,
+	}
+	board.sendSysex(msg)
+}
+
+// StepperStep moves the stepper motor identified by deviceNum steps steps
+// in direction (0 or 1) at the given speed, using accel/decel to ramp up
+// and down.
+func (board *Board) StepperStep(deviceNum, direction byte, steps, speed, accel, decel int) {
+	// steps is a 21 bit field (3 x 7 bit), wider than encode14 handles.
+	stepsLSB, stepsMSB := encode14(steps)
+	speedLSB, speedMSB := encode14(speed)
+	accelLSB, accelMSB := encode14(accel)
+	decelLSB, decelMSB := encode14(decel)
+	msg := []byte{
+		STEPPER_DATA, STEPPER_STEP, deviceNum & 0x7F, direction & 0x7F,
+		stepsLSB, stepsMSB, byte((steps >> 14) & 0x7F),
+		speedLSB, speedMSB,
+		accelLSB, accelMSB,
+		decelLSB, decelMSB,
+	}
+	board.sendSysex(msg)
+}
@@ -0,0 +1,38 @@
+package firmata
+
+import (
+	"reflect"
+	"testing"
+)
+
+// captureTransport is an in-memory Transport that records every write
+// instead of discarding it, so tests can assert on the wire bytes sent.
+type captureTransport struct {
+	memTransport
+	writes [][]byte
+}
+
+func (c *captureTransport) Write(p []byte) (int, error) {
+	cp := append([]byte(nil), p...)
+	c.writes = append(c.writes, cp)
+	return len(p), nil
+}
+
+func TestWriteServoAboveByteRange(t *testing.T) {
+	transport := &captureTransport{memTransport: *newMemTransport(nil)}
+	board := &Board{transport: transport}
+
+	board.WriteServo(5, 300)
+
+	if len(transport.writes) != 1 {
+		t.Fatalf("got %d writes, want 1", len(transport.writes))
+	}
+	lsb, msb := encode14(300)
+	want := []byte{byte(ANALOG_WRITE | 5), lsb, msb}
+	if !reflect.DeepEqual(transport.writes[0], want) {
+		t.Errorf("WriteServo(5, 300) sent %v, want %v", transport.writes[0], want)
+	}
+	if board.analogPins[5] != 300 {
+		t.Errorf("analogPins[5] = %d, want %d", board.analogPins[5], 300)
+	}
+}
@@ -0,0 +1,34 @@
+package firmata
+
+// encode7bit packs data into Firmata's standard 7-bit transport form:
+// each input byte becomes two output bytes, the low 7 bits followed by
+// the high bit, both masked to 7 bits so they never collide with the
+// 0x80-0xFF command range.
+func encode7bit(data []byte) []byte {
+	out := make([]byte, 0, len(data)*2)
+	for _, b := range data {
+		out = append(out, b&0x7F, (b>>7)&0x7F)
+	}
+	return out
+}
+
+// decode7bit is the inverse of encode7bit: it collapses consecutive pairs
+// of 7-bit bytes back into the original bytes.
+func decode7bit(data []byte) []byte {
+	out := make([]byte, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		out = append(out, data[i]|(data[i+1]<<7))
+	}
+	return out
+}
+
+// encode14 splits v into a little-endian pair of 7-bit bytes, as used by
+// Firmata for analog values and other 14 bit fields.
+func encode14(v int) (lsb, msb byte) {
+	return byte(v & 0x7F), byte((v >> 7) & 0x7F)
+}
+
+// decode14 is the inverse of encode14.
+func decode14(lsb, msb byte) int {
+	return int(lsb) | int(msb)<<7
+}
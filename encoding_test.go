@@ -0,0 +1,77 @@
+package firmata
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestEncode7bitExamples(t *testing.T) {
+	// Byte sequences from the Firmata 2.5 protocol spec examples.
+	tests := []struct {
+		name string
+		in   []byte
+		out  []byte
+	}{
+		{"zero", []byte{0x00}, []byte{0x00, 0x00}},
+		{"max byte", []byte{0xFF}, []byte{0x7F, 0x01}},
+		{"ascii A", []byte{'A'}, []byte{0x41, 0x00}},
+		{"two bytes", []byte{0x00, 0x80}, []byte{0x00, 0x00, 0x00, 0x01}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := encode7bit(tt.in)
+			if !reflect.DeepEqual(got, tt.out) {
+				t.Errorf("encode7bit(%v) = %v, want %v", tt.in, got, tt.out)
+			}
+			back := decode7bit(got)
+			if !reflect.DeepEqual(back, tt.in) {
+				t.Errorf("decode7bit(%v) = %v, want %v", got, back, tt.in)
+			}
+		})
+	}
+}
+
+func TestEncode7bitRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		data := make([]byte, r.Intn(32))
+		r.Read(data)
+		got := decode7bit(encode7bit(data))
+		if !reflect.DeepEqual(got, data) {
+			t.Fatalf("round trip mismatch for %v: got %v", data, got)
+		}
+	}
+}
+
+func TestEncode14(t *testing.T) {
+	tests := []struct {
+		v        int
+		lsb, msb byte
+	}{
+		{0, 0x00, 0x00},
+		{127, 0x7F, 0x00},
+		{128, 0x00, 0x01},
+		{16383, 0x7F, 0x7F},
+	}
+	for _, tt := range tests {
+		lsb, msb := encode14(tt.v)
+		if lsb != tt.lsb || msb != tt.msb {
+			t.Errorf("encode14(%d) = (%#x, %#x), want (%#x, %#x)", tt.v, lsb, msb, tt.lsb, tt.msb)
+		}
+		if got := decode14(lsb, msb); got != tt.v {
+			t.Errorf("decode14(%#x, %#x) = %d, want %d", lsb, msb, got, tt.v)
+		}
+	}
+}
+
+func TestEncode14RoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	for i := 0; i < 100; i++ {
+		v := r.Intn(16384)
+		lsb, msb := encode14(v)
+		if got := decode14(lsb, msb); got != v {
+			t.Fatalf("round trip mismatch for %d: got %d", v, got)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package firmata
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// FirmataError describes a failure encountered while talking to the
+// board. Op names the operation that failed (e.g. "read", "read sysex"),
+// Err is the underlying error, and Fatal reports whether the reader
+// goroutine gave up and stopped because of it.
+type FirmataError struct {
+	Op    string
+	Err   error
+	Fatal bool
+}
+
+func (e *FirmataError) Error() string {
+	return fmt.Sprintf("firmata: %s: %v", e.Op, e.Err)
+}
+
+func (e *FirmataError) Unwrap() error {
+	return e.Err
+}
+
+// Close stops the reader goroutine and closes the underlying transport.
+// It is safe to call even if the board is already closed.
+func (board *Board) Close() error {
+	board.cancelMu.Lock()
+	cancel := board.cancel
+	board.cancelMu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+	if board.transport == nil {
+		return nil
+	}
+	return board.transport.Close()
+}
+
+// BackoffConfig controls the delay between reconnect attempts made by
+// EnableAutoReconnect.
+type BackoffConfig struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+}
+
+// DefaultBackoff is a sensible starting point for EnableAutoReconnect.
+var DefaultBackoff = BackoffConfig{
+	Initial:    500 * time.Millisecond,
+	Max:        30 * time.Second,
+	Multiplier: 2,
+}
+
+// pinSnapshot is the state EnableAutoReconnect restores for a pin after
+// reopening the serial port.
+type pinSnapshot struct {
+	mode      byte
+	reporting bool
+}
+
+// EnableAutoReconnect watches board.Errors and, on a Fatal error,
+// reopens the transport, re-runs the capability/analog-mapping
+// handshake, and restores every pin's mode and report-enable state from
+// a snapshot of board.pins. It requires a board that knows how to redial
+// its transport, i.e. one opened with NewBoard. A board built with
+// NewBoardWithTransport, including one wrapping DialTCP or DialWebSocket,
+// has no way to redial and is not reconnected.
+func (board *Board) EnableAutoReconnect(backoff BackoffConfig) {
+	go func() {
+		for ferr := range board.Errors {
+			if !ferr.Fatal {
+				continue
+			}
+			log.Printf("firmata: %v, reconnecting", &ferr)
+			board.reconnect(backoff)
+		}
+	}()
+}
+
+// reconnect retries redialing the transport with an exponential backoff
+// until it succeeds, then restarts the reader and restores pin state.
+func (board *Board) reconnect(backoff BackoffConfig) {
+	if board.dial == nil {
+		log.Printf("firmata: cannot auto-reconnect: board has no way to redial its transport")
+		return
+	}
+	delay := backoff.Initial
+	for {
+		t, err := board.dial()
+		if err == nil {
+			board.transport = t
+			board.GetReader()
+			board.GetCapabilities()
+			board.GetAnalogMapping()
+			board.restorePinState()
+			return
+		}
+		log.Printf("firmata: reconnect to %s failed: %v", board.transport.Name(), err)
+		time.Sleep(delay)
+		delay = time.Duration(float64(delay) * backoff.Multiplier)
+		if delay > backoff.Max {
+			delay = backoff.Max
+		}
+	}
+}
+
+// restorePinState re-sends the mode and report-enable state of every
+// cached Pin, as recorded before the serial port was lost.
+func (board *Board) restorePinState() {
+	board.pinsMu.Lock()
+	snapshots := make(map[byte]pinSnapshot, len(board.pins))
+	for n, p := range board.pins {
+		snapshots[n] = pinSnapshot{mode: p.mode, reporting: len(p.onChange) > 0}
+	}
+	board.pinsMu.Unlock()
+
+	for n, snap := range snapshots {
+		if snap.mode != UNKNOWN {
+			board.SetPinMode(n, snap.mode)
+		}
+		if snap.reporting {
+			if snap.mode == MODE_ANALOG {
+				board.SetReadAnalog(n, 1)
+			} else {
+				board.SetReadDigital(n, 1)
+			}
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package firmata
+
+import (
+	"sync"
+	"testing"
+)
+
+// TestGetReaderKeepsChannelIdentity ensures a reconnect (which calls
+// GetReader again) doesn't hand consumers a new board.Reader channel,
+// since `for msg := range board.Reader` only ever sees the first one.
+func TestGetReaderKeepsChannelIdentity(t *testing.T) {
+	board := &Board{transport: newMemTransport(nil)}
+	board.GetReader()
+	first := board.Reader
+	board.cancel()
+
+	board.transport = newMemTransport(nil)
+	board.GetReader()
+	second := board.Reader
+	board.cancel()
+
+	if first != second {
+		t.Error("GetReader() replaced board.Reader on a second call; consumers ranging over the original channel would never see new messages")
+	}
+}
+
+// TestCloseDuringGetReaderNoRace exercises Close racing against a GetReader
+// call (as a fatal error mid-reconnect would do against a concurrent
+// shutdown); run with -race to catch unsynchronized access to board.cancel.
+func TestCloseDuringGetReaderNoRace(t *testing.T) {
+	board := &Board{transport: newMemTransport(nil)}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		board.GetReader()
+	}()
+	go func() {
+		defer wg.Done()
+		board.Close()
+	}()
+	wg.Wait()
+	board.Close()
+}
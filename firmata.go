@@ -27,11 +27,11 @@
 package firmata
 
 import (
+	"context"
 	"fmt"
-	"github.com/tarm/goserial"
-	"io"
 	"log"
 	"strconv"
+	"sync"
 	"time"
 )
 
@@ -96,38 +96,65 @@ type pinCapability struct {
 
 type Board struct {
 	Name            string
-	config          *serial.Config
 	device          string
 	Debug           int // 0 no debug
 	baud            int
-	serial          io.ReadWriteCloser
+	transport       Transport
+	dial            func() (Transport, error) // how to reopen transport; used by EnableAutoReconnect
 	Reader          chan FirmataMsg
 	Writer          chan FirmataMsg
 	digitalPins     [8]byte  // Keeps a record of digital pin values
-	analogPins      [16]byte // Keeps a record of analog pin values
+	analogPins      [16]int // Keeps a record of analog pin values, up to the 14-bit range ANALOG_MESSAGE and WriteServo carry
 	pinCapabilities []pinCapability
 	analogMappings  []byte // one for each pin showing mapped analog pin
 	version         map[string]byte
+	pinsMu          sync.Mutex
+	pins            map[byte]*Pin // cached high-level Pin handles, see Board.Pin
+	Errors          chan FirmataError
+	cancelMu        sync.Mutex
+	cancel          context.CancelFunc
+	waitersMu       sync.Mutex
+	waiters         map[byte]*waiter // one-shot waiters for Query* calls, keyed by response type
 }
 
 // Setup the board to start reading and writing
 // It needs a device in the format "/dev/ttyUSB0"
 // and a baud rate eg. 57600
 func NewBoard(device string, baud int) (*Board, error) {
-	board := new(Board)
+	dial := func() (Transport, error) { return DialSerial(device, baud) }
+	t, err := dial()
+	if err != nil {
+		return new(Board), err
+	}
+	board, err := NewBoardWithTransport(t)
 	board.device = device
 	board.baud = baud
-	board.config = &serial.Config{Name: board.device, Baud: board.baud}
-	var err error
-	board.serial, err = serial.OpenPort(board.config)
-	if err != nil {
-		log.Fatal("Could not open port")
-		return board, err
+	board.dial = dial
+	return board, err
+}
+
+// NewBoardWithTransport wraps an already-connected Transport in a Board.
+// Use it for transports other than a local serial port, e.g. DialTCP or
+// DialWebSocket, where NewBoard's device/baud signature doesn't apply.
+func NewBoardWithTransport(t Transport) (*Board, error) {
+	board := new(Board)
+	board.transport = t
+	board.pinCapabilities = make([]pinCapability, DefaultPinCount)
+	board.analogMappings = make([]byte, DefaultPinCount)
+	for i := range board.analogMappings {
+		board.analogMappings[i] = 0x7F // no analog channel mapped yet
 	}
 	board.GetReader()
-	board.GetCapabilities()
-	board.GetAnalogMapping()
-	return board, err
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultQueryTimeout)
+	defer cancel()
+	if _, err := board.QueryCapabilities(ctx); err != nil {
+		return board, fmt.Errorf("firmata: capability query: %w", err)
+	}
+	if _, err := board.QueryAnalogMapping(ctx); err != nil {
+		return board, fmt.Errorf("firmata: analog mapping query: %w", err)
+	}
+	return board, nil
 }
 
 // Return the Device  the board is using
@@ -150,7 +177,7 @@ func (board *Board) process_sysex(msgdata []byte) FirmataMsg {
 		result.data = make(map[string]string)
 		result.data["major"] = strconv.Itoa(int(msgdata[1]))
 		result.data["minor"] = strconv.Itoa(int(msgdata[2]))
-		result.data["name"] = string(msgdata[3:]) //TODO This needs to converted from 7bit
+		result.data["name"] = string(decode7bit(msgdata[3:]))
 	case CAPABILITY_RESPONSE:
 		var mode pinmode
 		var capa []pinmode
@@ -171,21 +198,28 @@ func (board *Board) process_sysex(msgdata []byte) FirmataMsg {
 			board.analogMappings[pin] = level
 		}
 	case PIN_STATE_RESPONSE:
+		result.data = make(map[string]string)
 		result.pin = msgdata[1]
-		result.data["mode"] = string(msgdata[2])
+		result.data["mode"] = strconv.Itoa(int(msgdata[2]))
 		state := 0
 		for mult, st := range msgdata[3:] {
-			state = state + int(st<<(7*uint(mult)))
+			state = state + int(st)<<(7*uint(mult))
 		}
-		result.data["state"] = string(state)
+		result.data["state"] = strconv.Itoa(state)
 	case I2C_REPLY:
-		result.data["address"] = string(toInt7(msgdata[1], msgdata[2]))
-		result.data["register"] = string(toInt7(msgdata[3], msgdata[4]))
-		data := ""
-		for f := 5; f < len(msgdata); f = f + 2 {
-			data = data + string(toInt7(msgdata[f], msgdata[f+1]))
-		}
-		result.data["i2cdata"] = data
+		result.data = make(map[string]string)
+		result.data["address"] = strconv.Itoa(decode14(msgdata[1], msgdata[2]))
+		result.data["register"] = strconv.Itoa(decode14(msgdata[3], msgdata[4]))
+		i2cdata := decode7bit(msgdata[5:])
+		result.data["i2cdata"] = string(i2cdata)
+	case ONEWIRE_DATA:
+		result.data = make(map[string]string)
+		result.data["subcommand"] = strconv.Itoa(int(msgdata[1]))
+		result.data["rawdata"] = string(decode7bit(msgdata[2:]))
+	case STEPPER_DATA:
+		result.data = make(map[string]string)
+		result.data["subcommand"] = strconv.Itoa(int(msgdata[1]))
+		result.data["device"] = strconv.Itoa(int(msgdata[2]))
 	default:
 		result.msgtype = UNKNOWN
 		result.data = make(map[string]string)
@@ -195,26 +229,26 @@ func (board *Board) process_sysex(msgdata []byte) FirmataMsg {
 	return result
 }
 
-func toInt7(lsb, msb byte) int {
-	return int(lsb + (msb << 7))
-}
-
-func (board *Board) processMIDI(cmd, first byte) FirmataMsg {
+func (board *Board) processMIDI(cmd, first byte) (FirmataMsg, error) {
 	var msg FirmataMsg
 	m := make([]byte, 2)
-	var err error
-	_, err = board.serial.Read(m)
-	if err != nil {
-		log.Fatal("Failed to read the rest of the MIDI message")
+	if _, err := board.transport.Read(m); err != nil {
+		return msg, err
 	}
 	msg.msgtype = cmd
 	switch cmd {
 	case ANALOG_MESSAGE:
 		pin := first & 0x0F
 		msg.pin = pin
-		value := m[0] | m[1]<<7
+		value := decode14(m[0], m[1])
 		msg.data = map[string]string{"value": fmt.Sprintf("%x", value)}
 		board.analogPins[pin] = value
+	case DIGITAL_WRITE: // digital I/O message, one byte per 8-pin port
+		port := first & 0x0F
+		msg.pin = port
+		bits := m[0] | m[1]<<7
+		msg.data = map[string]string{"value": fmt.Sprintf("%x", bits)}
+		board.digitalPins[port] = bits
 	case PROTOCOL_VER:
 		board.version = map[string]byte{"major": m[0], "minor": m[1]}
 		msg.data = map[string]string{
@@ -222,7 +256,7 @@ func (board *Board) processMIDI(cmd, first byte) FirmataMsg {
 			"minor_ver": fmt.Sprintf("%x", m[1]),
 		}
 	}
-	return msg
+	return msg, nil
 }
 
 // Show the board version
@@ -233,51 +267,117 @@ func (board *Board) Version() map[string]byte {
 
 // Sets up the reader channel
 // You can then fetch read events from  <- board.Reader
+// Any serial errors are sent on board.Errors instead of killing the
+// process; a Fatal one means the reader goroutine has stopped.
 func (board *Board) GetReader() {
-	board.Reader = make(chan FirmataMsg)
+	// board.Reader and board.Errors are created once and kept for the
+	// life of the Board: a reconnect calls GetReader again to restart
+	// the goroutine, and consumers ranging over either channel must keep
+	// seeing the same one across that restart.
+	if board.Reader == nil {
+		board.Reader = make(chan FirmataMsg)
+	}
+	if board.Errors == nil {
+		board.Errors = make(chan FirmataError, 8)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	board.cancelMu.Lock()
+	board.cancel = cancel
+	board.cancelMu.Unlock()
 	// Sleep for a bit before we start to read
 	time.Sleep(1000 * time.Millisecond)
-	go func() {
-		var err error
-		l := make([]byte, 1)
-		for _, err = board.serial.Read(l); ; _, err = board.serial.Read(l) {
+	go board.readLoop(ctx)
+}
+
+// readLoop reads and dispatches messages from the serial port until ctx
+// is cancelled or a read fails.
+func (board *Board) readLoop(ctx context.Context) {
+	l := make([]byte, 1)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+		if _, err := board.transport.Read(l); err != nil {
+			if ctx.Err() != nil {
+				return // Close was called; this is expected.
+			}
+			board.reportError("read", err, true)
+			return
+		}
+		switch l[0] {
+		case START_SYSEX:
+			msgdata, err := board.readSysex()
 			if err != nil {
-				print("Read Err: ")
-				fmt.Println(err)
-				log.Fatal("Failed to read from Serial port")
+				if ctx.Err() != nil {
+					return
+				}
+				board.reportError("read sysex", err, true)
 				return
 			}
-			switch l[0] {
-			case START_SYSEX:
-				var msgdata []byte
-				for m := make([]byte, 1); m[0] != END_SYSEX; _, err = board.serial.Read(m) {
-					if err != nil {
-						log.Fatal("Failed to read sysex from serial port")
-					} else {
-						msgdata = append(msgdata, m[0])
-					}
-				}
-				// Send the message down the chanel
-				newmsg := board.process_sysex(msgdata)
-				if board.Debug > 9 {
-					log.Printf("Sysex Rec: %v", newmsg)
-				}
+			// Send the message down the chanel
+			newmsg := board.process_sysex(msgdata)
+			if board.Debug > 9 {
+				log.Printf("Sysex Rec: %v", newmsg)
+			}
+			board.dispatch(newmsg)
+			// A Query* waiter that claims this response is the only
+			// consumer expecting it; NewBoard's startup handshake has no
+			// board.Reader reader yet, so sending every response there
+			// unconditionally would deadlock it against its own queries.
+			if !board.completeWaiter(newmsg) {
 				board.Reader <- newmsg
-			default:
-				var cmd byte
-				if l[0] < 240 {
-					cmd = l[0] & 0xF0
-				} else {
-					cmd = l[0]
-				}
-				if board.Debug > 9 {
-					log.Printf("Midi Rec: %v", cmd)
+			}
+		default:
+			var cmd byte
+			if l[0] < 240 {
+				cmd = l[0] & 0xF0
+			} else {
+				cmd = l[0]
+			}
+			if board.Debug > 9 {
+				log.Printf("Midi Rec: %v", cmd)
+			}
+			newmsg, err := board.processMIDI(cmd, l[0])
+			if err != nil {
+				if ctx.Err() != nil {
+					return
 				}
-				newmsg := board.processMIDI(cmd, l[0])
-				board.Reader <- newmsg
+				board.reportError("read midi", err, true)
+				return
 			}
+			board.dispatch(newmsg)
+			board.Reader <- newmsg
+		}
+	}
+}
+
+// readSysex reads bytes from the serial port up to and including the
+// terminating END_SYSEX, returning everything in between.
+func (board *Board) readSysex() ([]byte, error) {
+	var msgdata []byte
+	m := make([]byte, 1)
+	for {
+		if _, err := board.transport.Read(m); err != nil {
+			return nil, err
+		}
+		if m[0] == END_SYSEX {
+			return msgdata, nil
 		}
-	}()
+		msgdata = append(msgdata, m[0])
+	}
+}
+
+// reportError sends err on board.Errors, logging and dropping it instead
+// of blocking if nothing is reading from the channel.
+func (board *Board) reportError(op string, err error, fatal bool) {
+	ferr := FirmataError{Op: op, Err: err, Fatal: fatal}
+	select {
+	case board.Errors <- ferr:
+	default:
+		log.Printf("firmata: dropped error (no reader on board.Errors): %v", ferr)
+	}
 }
 
 // Expects the sysex message and just wraps it
@@ -294,7 +394,7 @@ func (board *Board) sendSysex(msg []byte) {
 }
 
 func (board *Board) sendRaw(msg *[]byte) {
-	board.serial.Write(*msg)
+	board.transport.Write(*msg)
 	if board.Debug > 9 {
 		log.Printf("RawMsg: %v\n", &msg)
 	}
@@ -349,7 +449,7 @@ func (board *Board) WriteAnalog(pin, value byte) {
 	msg := []byte{cmd, value & 0x7F, (value >> 7) & 0x7F}
 	board.sendRaw(&msg)
 	log.Printf("%v, %v", board.analogPins, pin)
-	board.analogPins[pin] = value
+	board.analogPins[pin] = int(value)
 }
 
 // Starts and stops the analog pin reporting
@@ -375,17 +475,14 @@ func (board *Board) SetReadDigital(pin, state byte) {
 func (board *Board) I2CConfig(delay int) {
 	msg := make([]byte, 3)
 	msg[0] = I2C_CONFIG
-	msg[1] = byte(1) // Power pins on
-	msg[1] = byte(delay & 0x7F)
-	msg[2] = byte((delay >> 7) & 0x7F)
+	msg[1], msg[2] = encode14(delay)
 	board.sendSysex(msg)
 }
 
 // Send analog report interval command
 // interval (millisecond)
 func (board *Board) SetSamplingInterval(interval int) {
-	msb := byte((interval << 1) >> 8 & 0x7F)
-	lsb := byte(interval & 0x7F)
+	lsb, msb := encode14(interval)
 	msg := []byte{SAMPLING_INTERVAL, lsb, msb}
 	board.sendSysex(msg)
 }
@@ -397,14 +494,8 @@ func (board *Board) SetSamplingInterval(interval int) {
 //       I2C_MODE_CONTINIOUS_READ or I2C_MODE_STOP_READING
 // We are only supporting 7bit addresses
 func (board *Board) I2CWrite(addr, mode byte, msg []byte) {
-	newLength := len(msg)*2 + 3
-	fullmsg := make([]byte, newLength)
-	fullmsg[0] = I2C_REQUEST
-	fullmsg[1] = addr & 0x7F
-	fullmsg[2] = mode << 3
-	for l := 0; l < len(msg); l++ {
-		fullmsg[3+l*2] = msg[l] & 0x7F
-		fullmsg[4+l*2] = msg[l] >> 7 & 0x7F
-	}
+	fullmsg := make([]byte, 0, len(msg)*2+3)
+	fullmsg = append(fullmsg, I2C_REQUEST, addr&0x7F, mode<<3)
+	fullmsg = append(fullmsg, encode7bit(msg)...)
 	board.sendSysex(fullmsg)
 }
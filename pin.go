@@ -0,0 +1,171 @@
+package firmata
+
+import "fmt"
+
+// Pin is a high-level handle to a single pin on the board. Get one with
+// Board.Pin; it caches the pin's last known value, validates mode changes
+// against the capabilities reported by the board, and delivers digital and
+// analog change events to any callbacks registered with OnChange.
+type Pin struct {
+	board    *Board
+	Number   byte
+	mode     byte
+	digital  byte
+	analog   int
+	onChange []func(value int)
+}
+
+// Pin returns the cached handle for pin n, creating it the first time it
+// is requested.
+func (board *Board) Pin(n byte) *Pin {
+	board.pinsMu.Lock()
+	defer board.pinsMu.Unlock()
+	if board.pins == nil {
+		board.pins = make(map[byte]*Pin)
+	}
+	if p, ok := board.pins[n]; ok {
+		return p
+	}
+	p := &Pin{board: board, Number: n, mode: UNKNOWN}
+	board.pins[n] = p
+	return p
+}
+
+// SetMode sets the pin's mode, rejecting it if the board's reported
+// capabilities don't include it.
+func (p *Pin) SetMode(mode byte) error {
+	if !p.board.supportsMode(p.Number, mode) {
+		return fmt.Errorf("firmata: pin %d does not support mode %#x", p.Number, mode)
+	}
+	p.board.pinsMu.Lock()
+	p.mode = mode
+	p.board.pinsMu.Unlock()
+	p.board.SetPinMode(p.Number, mode)
+	return nil
+}
+
+// DigitalWrite sets the pin high or low. value should be HIGH or LOW.
+func (p *Pin) DigitalWrite(value byte) {
+	p.board.WriteDigital(p.Number, value)
+	p.board.pinsMu.Lock()
+	p.digital = value
+	p.board.pinsMu.Unlock()
+}
+
+// DigitalRead returns the last digital value read or written for the pin.
+func (p *Pin) DigitalRead() byte {
+	p.board.pinsMu.Lock()
+	defer p.board.pinsMu.Unlock()
+	return p.digital
+}
+
+// AnalogRead returns the last analog value read or written for the pin.
+func (p *Pin) AnalogRead() int {
+	p.board.pinsMu.Lock()
+	defer p.board.pinsMu.Unlock()
+	return p.analog
+}
+
+// AnalogWrite writes a PWM value to the pin.
+func (p *Pin) AnalogWrite(value byte) {
+	p.board.WriteAnalog(p.Number, value)
+	p.board.pinsMu.Lock()
+	p.analog = int(value)
+	p.board.pinsMu.Unlock()
+}
+
+// ServoWrite moves a servo attached to the pin to the given angle, in
+// degrees. It delegates to Board.WriteServo, which carries the full
+// angle instead of truncating it to a byte.
+func (p *Pin) ServoWrite(angle int) {
+	p.board.WriteServo(p.Number, angle)
+	p.board.pinsMu.Lock()
+	p.analog = angle
+	p.board.pinsMu.Unlock()
+}
+
+// OnChange registers a callback that is invoked with the new value every
+// time a digital or analog event for the pin is read from the board. It
+// transparently enables reporting for the pin if it isn't already on.
+func (p *Pin) OnChange(cb func(value int)) {
+	p.board.pinsMu.Lock()
+	p.onChange = append(p.onChange, cb)
+	p.board.pinsMu.Unlock()
+	p.board.enableReporting(p.Number)
+}
+
+// notify runs the pin's registered callbacks with the given value.
+func (p *Pin) notify(value int) {
+	p.board.pinsMu.Lock()
+	callbacks := append([]func(value int){}, p.onChange...)
+	p.board.pinsMu.Unlock()
+	for _, cb := range callbacks {
+		cb(value)
+	}
+}
+
+// supportsMode reports whether pin supports mode, according to the
+// capabilities reported by the board. A pin with no entry in
+// board.pinCapabilities is treated permissively; a pin whose entry lists
+// no modes (such as NewBoardWithTransport's defaults before a capability
+// reply fills them in) supports none.
+func (board *Board) supportsMode(pin, mode byte) bool {
+	if int(pin) >= len(board.pinCapabilities) {
+		return true
+	}
+	for _, m := range board.pinCapabilities[pin].modes {
+		if m.mode == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// enableReporting turns on digital or analog reporting for pin, based on
+// its currently configured mode.
+func (board *Board) enableReporting(pin byte) {
+	p := board.Pin(pin)
+	board.pinsMu.Lock()
+	mode := p.mode
+	board.pinsMu.Unlock()
+	if mode == MODE_ANALOG {
+		board.SetReadAnalog(pin, 1)
+	} else {
+		board.SetReadDigital(pin, 1)
+	}
+}
+
+// dispatch demultiplexes a message read from the board to any cached Pin
+// with a matching pin number, updating its cached value and notifying its
+// OnChange callbacks.
+func (board *Board) dispatch(msg FirmataMsg) {
+	switch msg.msgtype {
+	case ANALOG_MESSAGE:
+		board.notifyPin(msg.pin, board.analogPins[msg.pin])
+	case DIGITAL_WRITE:
+		port := msg.pin
+		bits := board.digitalPins[port]
+		for bit := byte(0); bit < 8; bit++ {
+			pin := port*8 + bit
+			board.notifyPin(pin, int((bits>>bit)&1))
+		}
+	}
+}
+
+// notifyPin updates pin n's cached Pin, if one has been created, and
+// notifies its callbacks.
+func (board *Board) notifyPin(n byte, value int) {
+	board.pinsMu.Lock()
+	p, ok := board.pins[n]
+	if ok {
+		if p.mode == MODE_ANALOG || p.mode == MODE_PWM || p.mode == MODE_SERVO {
+			p.analog = value
+		} else {
+			p.digital = byte(value)
+		}
+	}
+	board.pinsMu.Unlock()
+	if ok {
+		p.notify(value)
+	}
+}
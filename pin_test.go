@@ -0,0 +1,30 @@
+package firmata
+
+import (
+	"sync"
+	"testing"
+)
+
+// TestPinConcurrentAccess exercises Pin.SetMode racing against the
+// dispatch path that Board.notifyPin runs from the reader goroutine; run
+// with -race to catch unsynchronized access to a Pin's mutable fields.
+func TestPinConcurrentAccess(t *testing.T) {
+	board := &Board{transport: newMemTransport(nil)}
+	pin := board.Pin(13)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			pin.SetMode(MODE_OUTPUT)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			board.notifyPin(13, i)
+		}
+	}()
+	wg.Wait()
+}
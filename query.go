@@ -0,0 +1,137 @@
+package firmata
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// DefaultPinCount sizes the pinCapabilities/analogMappings slices before
+// the board has told us how many pins it actually has.
+const DefaultPinCount = 128
+
+// DefaultQueryTimeout bounds how long NewBoard waits for the board to
+// answer the startup handshake queries. Set it before calling NewBoard
+// to change the timeout.
+var DefaultQueryTimeout = 5 * time.Second
+
+// Firmware is the name and version reported by QueryFirmware.
+type Firmware struct {
+	Major int
+	Minor int
+	Name  string
+}
+
+// PinState is a pin's current mode and value, as reported by
+// QueryPinState.
+type PinState struct {
+	Pin   byte
+	Mode  byte
+	State int
+}
+
+// PinCapability lists the modes, and their resolution, that a single pin
+// supports, as reported by QueryCapabilities.
+type PinCapability struct {
+	Modes []pinmode
+}
+
+// waiter is completed by the reader goroutine when the sysex response it
+// is waiting for arrives.
+type waiter struct {
+	ch chan FirmataMsg
+}
+
+// awaitResponse registers a one-shot waiter for responseType, runs send
+// to issue the request, then blocks until the matching response arrives
+// or ctx is done.
+func (board *Board) awaitResponse(ctx context.Context, responseType byte, send func()) (FirmataMsg, error) {
+	w := &waiter{ch: make(chan FirmataMsg, 1)}
+
+	board.waitersMu.Lock()
+	if board.waiters == nil {
+		board.waiters = make(map[byte]*waiter)
+	}
+	board.waiters[responseType] = w
+	board.waitersMu.Unlock()
+
+	send()
+
+	select {
+	case msg := <-w.ch:
+		return msg, nil
+	case <-ctx.Done():
+		board.waitersMu.Lock()
+		delete(board.waiters, responseType)
+		board.waitersMu.Unlock()
+		return FirmataMsg{}, ctx.Err()
+	}
+}
+
+// completeWaiter delivers msg to the waiter registered for its message
+// type, if any, and reports whether it did. Called by the reader
+// goroutine for every sysex message.
+func (board *Board) completeWaiter(msg FirmataMsg) bool {
+	board.waitersMu.Lock()
+	w, ok := board.waiters[msg.msgtype]
+	if ok {
+		delete(board.waiters, msg.msgtype)
+	}
+	board.waitersMu.Unlock()
+	if ok {
+		w.ch <- msg
+	}
+	return ok
+}
+
+// QueryCapabilities asks the board which modes each pin supports and
+// blocks until the reply arrives, populating board.pinCapabilities.
+func (board *Board) QueryCapabilities(ctx context.Context) ([]PinCapability, error) {
+	if _, err := board.awaitResponse(ctx, CAPABILITY_RESPONSE, board.GetCapabilities); err != nil {
+		return nil, err
+	}
+	result := make([]PinCapability, len(board.pinCapabilities))
+	for i, c := range board.pinCapabilities {
+		result[i] = PinCapability{Modes: c.modes}
+	}
+	return result, nil
+}
+
+// QueryAnalogMapping asks the board which pins have an analog channel
+// and blocks until the reply arrives, populating board.analogMappings.
+func (board *Board) QueryAnalogMapping(ctx context.Context) ([]byte, error) {
+	if _, err := board.awaitResponse(ctx, ANALOG_MAPPING_RESPONSE, board.GetAnalogMapping); err != nil {
+		return nil, err
+	}
+	return board.analogMappings, nil
+}
+
+// QueryFirmware asks the board for its firmware name and version and
+// blocks until the reply arrives.
+func (board *Board) QueryFirmware(ctx context.Context) (Firmware, error) {
+	msg, err := board.awaitResponse(ctx, REPORT_FIRMWARE, func() {
+		board.sendSysex([]byte{REPORT_FIRMWARE})
+	})
+	if err != nil {
+		return Firmware{}, err
+	}
+	major, _ := strconv.Atoi(msg.data["major"])
+	minor, _ := strconv.Atoi(msg.data["minor"])
+	return Firmware{Major: major, Minor: minor, Name: msg.data["name"]}, nil
+}
+
+// QueryPinState asks the board for pin's current mode and value and
+// blocks until the reply arrives. Callers should serialize concurrent
+// QueryPinState calls: a reply is matched by message type alone, so
+// overlapping queries for different pins can't be told apart.
+func (board *Board) QueryPinState(ctx context.Context, pin byte) (PinState, error) {
+	msg, err := board.awaitResponse(ctx, PIN_STATE_RESPONSE, func() {
+		board.sendSysex([]byte{PIN_STATE_QUERY, pin & 0x7F})
+	})
+	if err != nil {
+		return PinState{}, err
+	}
+	mode, _ := strconv.Atoi(msg.data["mode"])
+	state, _ := strconv.Atoi(msg.data["state"])
+	return PinState{Pin: msg.pin, Mode: byte(mode), State: state}, nil
+}
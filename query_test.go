@@ -0,0 +1,113 @@
+package firmata
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestQueryFirmware(t *testing.T) {
+	name := encode7bit([]byte("Go"))
+	sysex := append(append([]byte{START_SYSEX, REPORT_FIRMWARE, 2, 6}, name...), END_SYSEX)
+
+	board := &Board{transport: newMemTransport(sysex)}
+	board.Reader = make(chan FirmataMsg)
+	board.Errors = make(chan FirmataError, 1)
+	go func() {
+		for range board.Reader {
+		}
+	}()
+	go board.readLoop(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	fw, err := board.QueryFirmware(ctx)
+	if err != nil {
+		t.Fatalf("QueryFirmware() error = %v", err)
+	}
+	want := Firmware{Major: 2, Minor: 6, Name: "Go"}
+	if fw != want {
+		t.Errorf("QueryFirmware() = %+v, want %+v", fw, want)
+	}
+}
+
+// handshakeTransport replies to a CAPABILITY_QUERY or ANALOG_MAPPING_QUERY
+// sysex with its canned response, only once the query has actually been
+// written, like a real board would. This matters for
+// TestNewBoardWithTransportEndToEnd: queuing both responses up front (as a
+// plain memTransport would) lets the analog mapping response race ahead of
+// QueryAnalogMapping registering its waiter.
+type handshakeTransport struct {
+	out chan []byte
+	buf []byte
+}
+
+func newHandshakeTransport() *handshakeTransport {
+	return &handshakeTransport{out: make(chan []byte, 2)}
+}
+
+func (h *handshakeTransport) Read(p []byte) (int, error) {
+	for len(h.buf) == 0 {
+		chunk, ok := <-h.out
+		if !ok {
+			return 0, io.EOF
+		}
+		h.buf = chunk
+	}
+	n := copy(p, h.buf)
+	h.buf = h.buf[n:]
+	return n, nil
+}
+
+func (h *handshakeTransport) Write(p []byte) (int, error) {
+	if len(p) >= 2 {
+		switch p[1] {
+		case CAPABILITY_QUERY:
+			h.out <- append([]byte{START_SYSEX, CAPABILITY_RESPONSE, 0, 1, 127, 0}, END_SYSEX)
+		case ANALOG_MAPPING_QUERY:
+			h.out <- append([]byte{START_SYSEX, ANALOG_MAPPING_RESPONSE, 0x7F}, END_SYSEX)
+		}
+	}
+	return len(p), nil
+}
+
+func (h *handshakeTransport) Close() error { close(h.out); return nil }
+func (h *handshakeTransport) Name() string { return "handshake" }
+
+// TestNewBoardWithTransportEndToEnd drives the real startup handshake
+// through NewBoardWithTransport, with no manually-spawned goroutine
+// draining board.Reader: the capability and analog mapping responses
+// must be claimed by their Query* waiters rather than blocking on a send
+// to board.Reader that nothing is there yet to receive.
+func TestNewBoardWithTransportEndToEnd(t *testing.T) {
+	done := make(chan error, 1)
+	go func() {
+		_, err := NewBoardWithTransport(newHandshakeTransport())
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("NewBoardWithTransport() error = %v", err)
+		}
+	case <-time.After(DefaultQueryTimeout + time.Second):
+		t.Fatal("NewBoardWithTransport() never returned")
+	}
+}
+
+func TestQueryFirmwareTimesOut(t *testing.T) {
+	board := &Board{transport: newMemTransport(nil)}
+	board.Reader = make(chan FirmataMsg)
+	go func() {
+		for range board.Reader {
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := board.QueryFirmware(ctx); err == nil {
+		t.Error("QueryFirmware() error = nil, want a timeout error")
+	}
+}
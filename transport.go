@@ -0,0 +1,107 @@
+package firmata
+
+import (
+	"github.com/gorilla/websocket"
+	"github.com/tarm/goserial"
+	"io"
+	"net"
+)
+
+// Transport is the byte-level connection to a Firmata-speaking device.
+// Board talks to it the same way whether it's a local serial port, a
+// TCP socket (StandardFirmataWiFi, a Yún bridge, ...), or a WebSocket.
+type Transport interface {
+	Read(p []byte) (n int, err error)
+	Write(p []byte) (n int, err error)
+	Close() error
+	Name() string // a human-readable identifier, e.g. a device path or address
+}
+
+// SerialTransport is a Transport backed by a local serial port.
+type SerialTransport struct {
+	io.ReadWriteCloser
+	device string
+}
+
+// Name returns the serial device path, e.g. "/dev/ttyUSB0".
+func (s *SerialTransport) Name() string {
+	return s.device
+}
+
+// DialSerial opens a local serial port as a Transport. This is the
+// transport NewBoard uses.
+func DialSerial(device string, baud int) (Transport, error) {
+	port, err := serial.OpenPort(&serial.Config{Name: device, Baud: baud})
+	if err != nil {
+		return nil, err
+	}
+	return &SerialTransport{ReadWriteCloser: port, device: device}, nil
+}
+
+// TCPTransport is a Transport backed by a TCP connection, as used by
+// Firmata's networked variants (StandardFirmataWiFi on ESP8266/ESP32, or
+// a Yún's ttyATH0 bridged over TCP).
+type TCPTransport struct {
+	conn net.Conn
+	addr string
+}
+
+// DialTCP connects to a Firmata device listening on addr, e.g.
+// "192.168.1.50:3030".
+func DialTCP(addr string) (Transport, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &TCPTransport{conn: conn, addr: addr}, nil
+}
+
+func (t *TCPTransport) Read(p []byte) (int, error)  { return t.conn.Read(p) }
+func (t *TCPTransport) Write(p []byte) (int, error) { return t.conn.Write(p) }
+func (t *TCPTransport) Close() error                { return t.conn.Close() }
+func (t *TCPTransport) Name() string                { return t.addr }
+
+// WebSocketTransport is a Transport backed by a WebSocket connection,
+// for bridging Firmata to a browser or other host-side WebSocket client.
+// Firmata bytes are carried as binary WebSocket messages.
+type WebSocketTransport struct {
+	conn *websocket.Conn
+	url  string
+	buf  []byte // unread bytes from the most recent message
+}
+
+// DialWebSocket connects to a Firmata bridge over WebSocket, e.g.
+// "ws://localhost:8080/firmata".
+func DialWebSocket(url string) (Transport, error) {
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &WebSocketTransport{conn: conn, url: url}, nil
+}
+
+// Read fills p from the current WebSocket message, reading a new one
+// from the connection when the buffered bytes run out.
+func (w *WebSocketTransport) Read(p []byte) (int, error) {
+	for len(w.buf) == 0 {
+		_, data, err := w.conn.ReadMessage()
+		if err != nil {
+			return 0, err
+		}
+		w.buf = data
+	}
+	n := copy(p, w.buf)
+	w.buf = w.buf[n:]
+	return n, nil
+}
+
+// Write sends p as a single binary WebSocket message.
+func (w *WebSocketTransport) Write(p []byte) (int, error) {
+	if err := w.conn.WriteMessage(websocket.BinaryMessage, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *WebSocketTransport) Close() error { return w.conn.Close() }
+func (w *WebSocketTransport) Name() string { return w.url }
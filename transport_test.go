@@ -0,0 +1,55 @@
+package firmata
+
+import (
+	"bytes"
+	"testing"
+)
+
+// memTransport is an in-memory Transport used in tests: reads replay a
+// canned byte stream and writes are discarded.
+type memTransport struct {
+	r *bytes.Reader
+}
+
+func newMemTransport(data []byte) *memTransport {
+	return &memTransport{r: bytes.NewReader(data)}
+}
+
+func (m *memTransport) Read(p []byte) (int, error)  { return m.r.Read(p) }
+func (m *memTransport) Write(p []byte) (int, error) { return len(p), nil }
+func (m *memTransport) Close() error                { return nil }
+func (m *memTransport) Name() string                { return "mem" }
+
+func TestReadSysex(t *testing.T) {
+	name := encode7bit([]byte("Go"))
+	payload := append([]byte{REPORT_FIRMWARE, 2, 6}, name...)
+	stream := append(append([]byte{}, payload...), END_SYSEX)
+
+	board := &Board{transport: newMemTransport(stream)}
+	got, err := board.readSysex()
+	if err != nil {
+		t.Fatalf("readSysex() error = %v", err)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Errorf("readSysex() = %v, want %v", got, payload)
+	}
+}
+
+func TestProcessSysexReportFirmwareFromTransport(t *testing.T) {
+	name := encode7bit([]byte("Go"))
+	payload := append([]byte{REPORT_FIRMWARE, 2, 6}, name...)
+	stream := append(append([]byte{}, payload...), END_SYSEX)
+
+	board := &Board{transport: newMemTransport(stream)}
+	msgdata, err := board.readSysex()
+	if err != nil {
+		t.Fatalf("readSysex() error = %v", err)
+	}
+	msg := board.process_sysex(msgdata)
+	if msg.data["name"] != "Go" {
+		t.Errorf("name = %q, want %q", msg.data["name"], "Go")
+	}
+	if msg.data["major"] != "2" || msg.data["minor"] != "6" {
+		t.Errorf("version = %s.%s, want 2.6", msg.data["major"], msg.data["minor"])
+	}
+}